@@ -18,6 +18,9 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
+	"strconv"
+	"sync"
 )
 
 // aesKeyShuffle is the "AES Monte Carlo Key Shuffle" from the ACVP
@@ -140,6 +143,347 @@ func iterateAESCBC(transact func(n int, args ...[]byte) ([][]byte, error), encry
 	return mctResults
 }
 
+// incrementCounter increments a big-endian counter block in place, wrapping
+// on overflow, as required by the ACVP CTR Monte Carlo Test.
+func incrementCounter(counter []byte) {
+	for i := len(counter) - 1; i >= 0; i-- {
+		counter[i]++
+		if counter[i] != 0 {
+			break
+		}
+	}
+}
+
+// iterateAESCTR implements the ACVP Monte Carlo Test for AES-CTR. The
+// counter block is carried forward as the IV across the 1000 inner
+// iterations, incrementing as a big-endian counter, and is key-shuffled via
+// aesKeyShuffle between the 100 outer iterations.
+func iterateAESCTR(transact func(n int, args ...[]byte) ([][]byte, error), encrypt bool, key, input, iv []byte) (mctResults []blockCipherMCTResult) {
+	for i := 0; i < 100; i++ {
+		var iteration blockCipherMCTResult
+		iteration.KeyHex = hex.EncodeToString(key)
+		iteration.IVHex = hex.EncodeToString(iv)
+		if encrypt {
+			iteration.PlaintextHex = hex.EncodeToString(input)
+		} else {
+			iteration.CiphertextHex = hex.EncodeToString(input)
+		}
+
+		var result, prevResult []byte
+		for j := 0; j < 1000; j++ {
+			prevResult = result
+			results, err := transact(1, key, input, iv)
+			if err != nil {
+				panic("block operation failed: " + err.Error())
+			}
+			result = results[0]
+			input = result
+			incrementCounter(iv)
+		}
+
+		if encrypt {
+			iteration.CiphertextHex = hex.EncodeToString(result)
+		} else {
+			iteration.PlaintextHex = hex.EncodeToString(result)
+		}
+
+		aesKeyShuffle(key, result, prevResult)
+		input = result
+
+		mctResults = append(mctResults, iteration)
+	}
+
+	return mctResults
+}
+
+// iterateAESCFB128 implements the ACVP Monte Carlo Test for AES-CFB128 by
+// delegating to iterateAESCBC, so it chains blocks the same way: the next
+// IV is the previous output when encrypting and the previous input when
+// decrypting, and the next input is the output from two iterations back
+// (the initial IV for the second iteration).
+func iterateAESCFB128(transact func(n int, args ...[]byte) ([][]byte, error), encrypt bool, key, input, iv []byte) (mctResults []blockCipherMCTResult) {
+	return iterateAESCBC(transact, encrypt, key, input, iv)
+}
+
+// iterateAESOFB implements the ACVP Monte Carlo Test for AES-OFB. Unlike
+// CBC/CFB128, the register fed back into the module as the next IV is the
+// keystream block the module produced (O[j] = result XOR input), not the
+// plaintext/ciphertext itself; the next input, and the key shuffle, still
+// follow the same "previous result becomes next input" pattern as the
+// other modes.
+func iterateAESOFB(transact func(n int, args ...[]byte) ([][]byte, error), encrypt bool, key, input, iv []byte) (mctResults []blockCipherMCTResult) {
+	for i := 0; i < 100; i++ {
+		var iteration blockCipherMCTResult
+		iteration.KeyHex = hex.EncodeToString(key)
+		iteration.IVHex = hex.EncodeToString(iv)
+		if encrypt {
+			iteration.PlaintextHex = hex.EncodeToString(input)
+		} else {
+			iteration.CiphertextHex = hex.EncodeToString(input)
+		}
+
+		var result, prevResult []byte
+		for j := 0; j < 1000; j++ {
+			prevResult = result
+			results, err := transact(1, key, input, iv)
+			if err != nil {
+				panic("block operation failed: " + err.Error())
+			}
+			result = results[0]
+
+			keystream := make([]byte, len(result))
+			for k := range keystream {
+				keystream[k] = result[k] ^ input[k]
+			}
+			iv = keystream
+			input = result
+		}
+
+		if encrypt {
+			iteration.CiphertextHex = hex.EncodeToString(result)
+		} else {
+			iteration.PlaintextHex = hex.EncodeToString(result)
+		}
+
+		aesKeyShuffle(key, result, prevResult)
+		input = result
+
+		mctResults = append(mctResults, iteration)
+	}
+
+	return mctResults
+}
+
+// iterateAESCFB8 implements the ACVP Monte Carlo Test for AES-CFB8. Each of
+// the 1000 inner iterations processes a single byte, shifting it into the
+// 16-byte IV register: IV := IV<<8 | CT_j for encrypt, IV := IV<<8 | PT_j
+// for decrypt. Between the 100 outer iterations, aesKeyShuffle is applied
+// against the last 16 output bytes produced by the inner loop.
+func iterateAESCFB8(transact func(n int, args ...[]byte) ([][]byte, error), encrypt bool, key, input, iv []byte) (mctResults []blockCipherMCTResult) {
+	for i := 0; i < 100; i++ {
+		var iteration blockCipherMCTResult
+		iteration.KeyHex = hex.EncodeToString(key)
+		iteration.IVHex = hex.EncodeToString(iv)
+		if encrypt {
+			iteration.PlaintextHex = hex.EncodeToString(input)
+		} else {
+			iteration.CiphertextHex = hex.EncodeToString(input)
+		}
+
+		// window holds the last 32 output bytes: window[16:] feeds
+		// aesKeyShuffle as "result" and window[:16] as "prevResult",
+		// exactly as the trailing two blocks do in the block-mode MCTs.
+		window := make([]byte, 32)
+		var lastOutput byte
+
+		for j := 0; j < 1000; j++ {
+			results, err := transact(1, key, input, iv)
+			if err != nil {
+				panic("block operation failed: " + err.Error())
+			}
+			output := results[0][0]
+			lastOutput = output
+
+			// The byte shifted in is this iteration's output: CT_j when
+			// encrypting, PT_j when decrypting.
+			iv = append(append([]byte{}, iv[1:]...), output)
+			input = []byte{output}
+
+			window = append(window[1:], output)
+		}
+
+		if encrypt {
+			iteration.CiphertextHex = hex.EncodeToString([]byte{lastOutput})
+		} else {
+			iteration.PlaintextHex = hex.EncodeToString([]byte{lastOutput})
+		}
+
+		aesKeyShuffle(key, window[16:], window[:16])
+		mctResults = append(mctResults, iteration)
+	}
+
+	return mctResults
+}
+
+// shiftInBit shifts a big-endian bit register left by one bit, in place,
+// and ORs the given bit into the newly vacated low bit.
+func shiftInBit(register []byte, bit byte) {
+	carry := bit & 1
+	for i := len(register) - 1; i >= 0; i-- {
+		nextCarry := register[i] >> 7
+		register[i] = (register[i] << 1) | carry
+		carry = nextCarry
+	}
+}
+
+// iterateAESCFB1 implements the ACVP Monte Carlo Test for AES-CFB1. It is
+// the bitwise analogue of iterateAESCFB8: each inner iteration processes a
+// single bit, shifting it into the 128-bit IV register with shiftInBit, and
+// the outer key shuffle is applied against the last 128 output bits,
+// packed into two 16-byte registers the same way the block-mode MCTs use
+// the last two output blocks.
+func iterateAESCFB1(transact func(n int, args ...[]byte) ([][]byte, error), encrypt bool, key, input, iv []byte) (mctResults []blockCipherMCTResult) {
+	const resultBits = 128
+
+	for i := 0; i < 100; i++ {
+		var iteration blockCipherMCTResult
+		iteration.KeyHex = hex.EncodeToString(key)
+		iteration.IVHex = hex.EncodeToString(iv)
+		if encrypt {
+			iteration.PlaintextHex = hex.EncodeToString(input)
+		} else {
+			iteration.CiphertextHex = hex.EncodeToString(input)
+		}
+
+		var bits, prevBits [16]byte
+		var lastOutput byte
+
+		for j := 0; j < 1000; j++ {
+			results, err := transact(1, key, input, iv)
+			if err != nil {
+				panic("block operation failed: " + err.Error())
+			}
+			output := results[0][0] & 1
+			lastOutput = output
+
+			shiftInBit(iv, output)
+			input = []byte{output}
+
+			shiftInBit(bits[:], output)
+			if j == 1000-resultBits-1 {
+				prevBits = bits
+			}
+		}
+
+		if encrypt {
+			iteration.CiphertextHex = hex.EncodeToString([]byte{lastOutput})
+		} else {
+			iteration.PlaintextHex = hex.EncodeToString([]byte{lastOutput})
+		}
+
+		aesKeyShuffle(key, bits[:], prevBits[:])
+		mctResults = append(mctResults, iteration)
+	}
+
+	return mctResults
+}
+
+// aesGCMSIVTagLen is the length, in bytes, of the authentication tag
+// produced by AES-GCM-SIV, per RFC 8452.
+const aesGCMSIVTagLen = 16
+
+// iterateAESGCMSIV implements the ACVP AEAD Monte Carlo Test for
+// AES-GCM-SIV. Each inner iteration feeds that round's ciphertext and tag
+// back into the nonce and AAD of the next operation, per the ACVP AEAD MCT
+// rules. The outer key shuffle runs against the 16-byte tag rather than
+// the ciphertext, since the tag is always block-sized while the
+// ciphertext, which matches the plaintext length, may be shorter (even
+// empty) and would otherwise make aesKeyShuffle index out of range.
+//
+// The decrypt direction has no seal oracle of its own to mint a fresh,
+// authenticating ciphertext/tag for each new nonce/AAD, so after decrypting
+// a round it re-encrypts that round's recovered plaintext under the next
+// round's nonce/AAD to produce the pair the next round decrypts - transact
+// takes an explicit op so both encryptOp and decryptOp are reachable from a
+// single decrypt-direction test case. If a decrypt ever fails to
+// authenticate (zero-length result, the same convention processTest's AFT
+// path uses), the MCT stops immediately and reports it as failed rather
+// than indexing into an empty result.
+func iterateAESGCMSIV(transact func(op string, n int, args ...[]byte) ([][]byte, error), encryptOp, decryptOp string, encrypt bool, key, input, iv, aad, tag []byte) (mctResults []blockCipherMCTResult, passed *bool) {
+	nonce := append([]byte{}, iv...)
+	aad = append([]byte{}, aad...)
+	tag = append([]byte{}, tag...)
+	ciphertext := append([]byte{}, input...)
+
+	for i := 0; i < 100; i++ {
+		var iteration blockCipherMCTResult
+		iteration.KeyHex = hex.EncodeToString(key)
+		iteration.IVHex = hex.EncodeToString(nonce)
+		iteration.AADHex = hex.EncodeToString(aad)
+		if encrypt {
+			iteration.PlaintextHex = hex.EncodeToString(input)
+		} else {
+			iteration.CiphertextHex = hex.EncodeToString(ciphertext)
+		}
+
+		var plaintext, prevTag []byte
+		for j := 0; j < 1000; j++ {
+			prevTag = tag
+
+			if encrypt {
+				results, err := transact(encryptOp, 1, key, nonce, input, aad)
+				if err != nil {
+					panic("AEAD operation failed: " + err.Error())
+				}
+				sealed := results[0]
+				ciphertext = sealed[:len(sealed)-aesGCMSIVTagLen]
+				tag = sealed[len(sealed)-aesGCMSIVTagLen:]
+				input = ciphertext
+			} else {
+				sealed := append(append([]byte{}, ciphertext...), tag...)
+				results, err := transact(decryptOp, 1, key, nonce, sealed, aad)
+				if err != nil {
+					panic("AEAD operation failed: " + err.Error())
+				}
+				if len(results) == 0 {
+					failed := false
+					return mctResults, &failed
+				}
+				plaintext = results[0]
+			}
+
+			// Feed this round's ciphertext and tag back into the nonce and
+			// AAD, per the ACVP AEAD MCT rules.
+			feedback := append(append([]byte{}, ciphertext...), tag...)
+			nonce = feedback[:len(nonce)]
+			aad = tag
+
+			if !encrypt {
+				results, err := transact(encryptOp, 1, key, nonce, plaintext, aad)
+				if err != nil {
+					panic("AEAD operation failed: " + err.Error())
+				}
+				sealed := results[0]
+				ciphertext = sealed[:len(sealed)-aesGCMSIVTagLen]
+				tag = sealed[len(sealed)-aesGCMSIVTagLen:]
+			}
+		}
+
+		iteration.TagHex = hex.EncodeToString(tag)
+		if encrypt {
+			iteration.CiphertextHex = hex.EncodeToString(ciphertext)
+		} else {
+			iteration.PlaintextHex = hex.EncodeToString(plaintext)
+		}
+
+		switch len(key) {
+		case 16, 32:
+			aesKeyShuffle(key, tag, prevTag)
+		default:
+			panic("AES-GCM-SIV only supports 128- and 256-bit keys")
+		}
+
+		// The key shuffle above just changed key, but for the decrypt
+		// direction the ciphertext/tag carried into the next outer
+		// iteration were minted under the pre-shuffle key. Re-mint them
+		// under the new key so the next outer iteration's first decrypt
+		// authenticates instead of failing immediately.
+		if !encrypt {
+			results, err := transact(encryptOp, 1, key, nonce, plaintext, aad)
+			if err != nil {
+				panic("AEAD operation failed: " + err.Error())
+			}
+			sealed := results[0]
+			ciphertext = sealed[:len(sealed)-aesGCMSIVTagLen]
+			tag = sealed[len(sealed)-aesGCMSIVTagLen:]
+		}
+
+		mctResults = append(mctResults, iteration)
+	}
+
+	return mctResults, nil
+}
+
 // blockCipher implements an ACVP algorithm by making requests to the subprocess
 // to encrypt and decrypt with a block cipher.
 type blockCipher struct {
@@ -147,9 +491,84 @@ type blockCipher struct {
 	blockSize               int
 	inputsAreBlockMultiples bool
 	hasIV                   bool
-	mctFunc                 func(transact func(n int, args ...[]byte) ([][]byte, error), encrypt bool, key, input, iv []byte) (result []blockCipherMCTResult)
+	// aead is true for algorithms, such as AES-GCM-SIV, that carry AAD and
+	// an authentication tag alongside the usual key/plaintext/ciphertext
+	// fields and whose decrypt direction can fail authentication.
+	aead bool
+	// workers bounds how many test cases and groups Process drives
+	// concurrently; see resolveWorkerCount for how it combines with
+	// ACVP_WORKERS. Zero means "use the environment/default".
+	workers int
+	mctFunc func(transact func(n int, args ...[]byte) ([][]byte, error), encrypt bool, key, input, iv []byte) (result []blockCipherMCTResult)
+	// aeadMCTFunc is mctFunc's counterpart for aead algorithms: it takes
+	// the test's AAD and (for decrypt) tag, since those feed the AEAD MCT
+	// chain too, an explicit op per call since a decrypt-direction MCT may
+	// need to invoke the paired encrypt op as well, and returns a Passed
+	// verdict alongside the usual results for when decryption fails to
+	// authenticate partway through. Exactly one of mctFunc and aeadMCTFunc
+	// is set, matching aead.
+	aeadMCTFunc func(transact func(op string, n int, args ...[]byte) ([][]byte, error), encryptOp, decryptOp string, encrypt bool, key, input, iv, aad, tag []byte) (result []blockCipherMCTResult, passed *bool)
+}
+
+// resolveWorkerCount determines how many goroutines Process uses to drive
+// independent test cases and groups concurrently. An explicit, positive
+// configured value takes precedence; otherwise the ACVP_WORKERS
+// environment variable is consulted. The default, 1, preserves the
+// original strictly-sequential behavior.
+//
+// Only the independent test cases of a group (and the groups
+// themselves) are parallelized this way: the 1000-iteration inner chain of
+// a single MCT test case is sequential by construction (each block depends
+// on the previous one), as is the key shuffle between one test case's own
+// outer iterations, so a single MCT test case always runs start-to-finish
+// on one goroutine.
+func resolveWorkerCount(configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	if v := os.Getenv("ACVP_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 1
+}
+
+// concurrencySafeTransactable is implemented by Transactable implementations
+// that are already safe to call from multiple goroutines at once.
+// blockCipher.Process consults this via a type assertion rather than
+// trusting its caller to have wrapped an unsafe Transactable themselves:
+// anything that doesn't implement it is wrapped with newLockedTransactable
+// automatically before any worker count above 1 is allowed to dispatch
+// concurrent Transact calls against it.
+type concurrencySafeTransactable interface {
+	Transactable
+	concurrencySafe()
+}
+
+// lockedTransactable adapts a Transactable that is not safe for concurrent
+// use so that it can be passed to blockCipher's worker pool, by serializing
+// all calls with a mutex. Note that doing so only protects against data
+// races — it does not parallelize the underlying implementation.
+type lockedTransactable struct {
+	mu    sync.Mutex
+	inner Transactable
+}
+
+func newLockedTransactable(inner Transactable) Transactable {
+	return &lockedTransactable{inner: inner}
 }
 
+func (l *lockedTransactable) Transact(op string, expectedResults int, args ...[]byte) ([][]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inner.Transact(op, expectedResults, args...)
+}
+
+// concurrencySafe marks lockedTransactable as safe for Process to dispatch
+// concurrent Transact calls against, satisfying concurrencySafeTransactable.
+func (l *lockedTransactable) concurrencySafe() {}
+
 type blockCipherVectorSet struct {
 	Groups []blockCipherTestGroup `json:"testGroups"`
 }
@@ -159,12 +578,19 @@ type blockCipherTestGroup struct {
 	Type      string `json:"testType"`
 	Direction string `json:"direction"`
 	KeyBits   int    `json:"keylen"`
-	Tests     []struct {
+	// PayloadLen is in bytes for AES-CTR and in bits for AES-CFB1, matching
+	// what each algorithm's ACVP test groups specify.
+	PayloadLen         int    `json:"payloadLen,omitempty"`
+	CTRSource          string `json:"ctrSource,omitempty"`
+	IncrementalCounter *bool  `json:"incrementalCounter,omitempty"`
+	Tests              []struct {
 		ID            uint64 `json:"tcId"`
 		PlaintextHex  string `json:"pt"`
 		CiphertextHex string `json:"ct"`
 		IVHex         string `json:"iv"`
 		KeyHex        string `json:"key"`
+		AADHex        string `json:"aad,omitempty"`
+		TagHex        string `json:"tag,omitempty"`
 	} `json:"tests"`
 }
 
@@ -177,6 +603,8 @@ type blockCipherTestResponse struct {
 	ID            uint64                 `json:"tcId"`
 	CiphertextHex string                 `json:"ct,omitempty"`
 	PlaintextHex  string                 `json:"pt,omitempty"`
+	TagHex        string                 `json:"tag,omitempty"`
+	Passed        *bool                  `json:"testPassed,omitempty"`
 	MCTResults    []blockCipherMCTResult `json:"resultsArray,omitempty"`
 }
 
@@ -185,6 +613,19 @@ type blockCipherMCTResult struct {
 	PlaintextHex  string `json:"pt"`
 	CiphertextHex string `json:"ct"`
 	IVHex         string `json:"iv,omitempty"`
+	AADHex        string `json:"aad,omitempty"`
+	TagHex        string `json:"tag,omitempty"`
+}
+
+// blockCipherGroupContext holds the per-group state that processTest needs,
+// computed once per group so that processTest can be invoked concurrently
+// for each of the group's independent test cases.
+type blockCipherGroupContext struct {
+	group    blockCipherTestGroup
+	encrypt  bool
+	mct      bool
+	op       string
+	keyBytes int
 }
 
 func (b *blockCipher) Process(vectorSet []byte, m Transactable) (interface{}, error) {
@@ -193,116 +634,251 @@ func (b *blockCipher) Process(vectorSet []byte, m Transactable) (interface{}, er
 		return nil, err
 	}
 
-	var ret []blockCipherTestGroupResponse
+	ret := make([]blockCipherTestGroupResponse, len(parsed.Groups))
+	contexts := make([]blockCipherGroupContext, len(parsed.Groups))
+
 	// See
 	// http://usnistgov.github.io/ACVP/artifacts/draft-celi-acvp-block-ciph-00.html#rfc.section.5.2
 	// for details about the tests.
-	for _, group := range parsed.Groups {
-		response := blockCipherTestGroupResponse{
-			ID: group.ID,
-		}
+	for i, group := range parsed.Groups {
+		ret[i].ID = group.ID
+		ret[i].Tests = make([]blockCipherTestResponse, len(group.Tests))
+
+		ctx := blockCipherGroupContext{group: group}
 
-		var encrypt bool
 		switch group.Direction {
 		case "encrypt":
-			encrypt = true
+			ctx.encrypt = true
 		case "decrypt":
-			encrypt = false
+			ctx.encrypt = false
 		default:
 			return nil, fmt.Errorf("test group %d has unknown direction %q", group.ID, group.Direction)
 		}
 
-		op := b.algo + "/encrypt"
-		if !encrypt {
-			op = b.algo + "/decrypt"
+		ctx.op = b.algo + "/encrypt"
+		if !ctx.encrypt {
+			ctx.op = b.algo + "/decrypt"
 		}
 
-		var mct bool
 		switch group.Type {
 		case "AFT", "CTR":
-			mct = false
+			ctx.mct = false
 		case "MCT":
-			if b.mctFunc == nil {
-				return nil, fmt.Errorf("test group %d has type MCT which is unsupported for %q", group.ID, op)
+			if b.mctFunc == nil && b.aeadMCTFunc == nil {
+				return nil, fmt.Errorf("test group %d has type MCT which is unsupported for %q", group.ID, ctx.op)
 			}
-			mct = true
+			ctx.mct = true
 		default:
 			return nil, fmt.Errorf("test group %d has unknown type %q", group.ID, group.Type)
 		}
 
+		if b.algo == "AES-CTR" {
+			if group.CTRSource != "" && group.CTRSource != "internal" {
+				return nil, fmt.Errorf("test group %d uses unsupported counter source %q", group.ID, group.CTRSource)
+			}
+			if group.IncrementalCounter != nil && !*group.IncrementalCounter {
+				return nil, fmt.Errorf("test group %d requires a non-incrementing counter, which is unsupported", group.ID)
+			}
+		}
+
 		if group.KeyBits%8 != 0 {
 			return nil, fmt.Errorf("test group %d contains non-byte-multiple key length %d", group.ID, group.KeyBits)
 		}
-		keyBytes := group.KeyBits / 8
+		ctx.keyBytes = group.KeyBits / 8
 
-		transact := func(n int, args ...[]byte) ([][]byte, error) {
-			return m.Transact(op, n, args...)
+		contexts[i] = ctx
+	}
+
+	// Test cases are independent of one another, both within a group and
+	// across groups, so they're dispatched to a bounded worker pool. A
+	// single MCT test case's 100x1000 iteration chain is itself sequential
+	// (see resolveWorkerCount) and always runs entirely within one
+	// goroutine.
+	workers := resolveWorkerCount(b.workers)
+
+	// More than one worker means concurrent Transact calls against m.
+	// Rather than trust that the caller already wrapped an unsafe m with
+	// newLockedTransactable, check here: anything that doesn't self-report
+	// as concurrency-safe gets wrapped automatically, so ACVP_WORKERS can
+	// never silently corrupt an IPC-framed Transactable's state.
+	if workers > 1 {
+		if _, ok := m.(concurrencySafeTransactable); !ok {
+			m = newLockedTransactable(m)
 		}
+	}
 
-		for _, test := range group.Tests {
-			if len(test.KeyHex) != keyBytes*2 {
-				return nil, fmt.Errorf("test case %d/%d contains key %q of length %d, but expected %d-bit key", group.ID, test.ID, test.KeyHex, len(test.KeyHex), group.KeyBits)
-			}
+	type task struct {
+		groupIdx, testIdx int
+	}
+	var tasks []task
+	for i, ctx := range contexts {
+		for j := range ctx.group.Tests {
+			tasks = append(tasks, task{i, j})
+		}
+	}
 
-			key, err := hex.DecodeString(test.KeyHex)
+	sem := make(chan struct{}, workers)
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for n, t := range tasks {
+		n, t := n, t
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ctx := contexts[t.groupIdx]
+			resp, err := b.processTest(ctx, m, ctx.group.Tests[t.testIdx])
 			if err != nil {
-				return nil, fmt.Errorf("failed to decode hex in test case %d/%d: %s", group.ID, test.ID, err)
+				errs[n] = err
+				return
 			}
+			ret[t.groupIdx].Tests[t.testIdx] = resp
+		}()
+	}
+	wg.Wait()
 
-			var inputHex string
-			if encrypt {
-				inputHex = test.PlaintextHex
-			} else {
-				inputHex = test.CiphertextHex
-			}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			input, err := hex.DecodeString(inputHex)
-			if err != nil {
-				return nil, fmt.Errorf("failed to decode hex in test case %d/%d: %s", group.ID, test.ID, err)
-			}
+	return ret, nil
+}
 
-			if b.inputsAreBlockMultiples && len(input)%b.blockSize != 0 {
-				return nil, fmt.Errorf("test case %d/%d has input of length %d, but expected multiple of %d", group.ID, test.ID, len(input), b.blockSize)
-			}
+// processTest runs a single test case, returning the response to record at
+// its original position in the group's Tests slice. It performs no shared
+// mutation, so it's safe to call concurrently for different test cases as
+// long as m is safe for concurrent use.
+func (b *blockCipher) processTest(ctx blockCipherGroupContext, m Transactable, test struct {
+	ID            uint64 `json:"tcId"`
+	PlaintextHex  string `json:"pt"`
+	CiphertextHex string `json:"ct"`
+	IVHex         string `json:"iv"`
+	KeyHex        string `json:"key"`
+	AADHex        string `json:"aad,omitempty"`
+	TagHex        string `json:"tag,omitempty"`
+}) (blockCipherTestResponse, error) {
+	group, encrypt, mct, op := ctx.group, ctx.encrypt, ctx.mct, ctx.op
 
-			var iv []byte
-			if b.hasIV {
-				if iv, err = hex.DecodeString(test.IVHex); err != nil {
-					return nil, fmt.Errorf("failed to decode hex in test case %d/%d: %s", group.ID, test.ID, err)
-				}
-				if len(iv) != b.blockSize {
-					return nil, fmt.Errorf("test case %d/%d has IV of length %d, but expected %d", group.ID, test.ID, len(iv), b.blockSize)
-				}
-			}
+	if len(test.KeyHex) != ctx.keyBytes*2 {
+		return blockCipherTestResponse{}, fmt.Errorf("test case %d/%d contains key %q of length %d, but expected %d-bit key", group.ID, test.ID, test.KeyHex, len(test.KeyHex), group.KeyBits)
+	}
 
-			testResp := blockCipherTestResponse{ID: test.ID}
-			if !mct {
-				var result [][]byte
-				var err error
+	key, err := hex.DecodeString(test.KeyHex)
+	if err != nil {
+		return blockCipherTestResponse{}, fmt.Errorf("failed to decode hex in test case %d/%d: %s", group.ID, test.ID, err)
+	}
 
-				if b.hasIV {
-					result, err = m.Transact(op, 1, key, input, iv)
-				} else {
-					result, err = m.Transact(op, 1, key, input)
-				}
-				if err != nil {
-					panic("block operation failed: " + err.Error())
-				}
+	var inputHex string
+	if encrypt {
+		inputHex = test.PlaintextHex
+	} else {
+		inputHex = test.CiphertextHex
+	}
 
-				if encrypt {
-					testResp.CiphertextHex = hex.EncodeToString(result[0])
-				} else {
-					testResp.PlaintextHex = hex.EncodeToString(result[0])
-				}
-			} else {
-				testResp.MCTResults = b.mctFunc(transact, encrypt, key, input, iv)
-			}
+	input, err := hex.DecodeString(inputHex)
+	if err != nil {
+		return blockCipherTestResponse{}, fmt.Errorf("failed to decode hex in test case %d/%d: %s", group.ID, test.ID, err)
+	}
+
+	if b.inputsAreBlockMultiples && len(input)%b.blockSize != 0 {
+		return blockCipherTestResponse{}, fmt.Errorf("test case %d/%d has input of length %d, but expected multiple of %d", group.ID, test.ID, len(input), b.blockSize)
+	}
 
-			response.Tests = append(response.Tests, testResp)
+	if group.PayloadLen != 0 {
+		// PayloadLen is in bits for AES-CFB1 and in bytes for everything
+		// else that sets it (AES-CTR), per blockCipherTestGroup's doc
+		// comment.
+		gotLen := len(input)
+		if b.algo == "AES-CFB1" {
+			gotLen *= 8
+		}
+		if gotLen != group.PayloadLen {
+			return blockCipherTestResponse{}, fmt.Errorf("test case %d/%d has payload of length %d, but group specifies payloadLen %d", group.ID, test.ID, gotLen, group.PayloadLen)
 		}
+	}
 
-		ret = append(ret, response)
+	var iv []byte
+	if b.hasIV {
+		if iv, err = hex.DecodeString(test.IVHex); err != nil {
+			return blockCipherTestResponse{}, fmt.Errorf("failed to decode hex in test case %d/%d: %s", group.ID, test.ID, err)
+		}
+		// AEAD nonces (e.g. the 96-bit AES-GCM-SIV nonce) need not match
+		// the underlying block size.
+		if !b.aead && len(iv) != b.blockSize {
+			return blockCipherTestResponse{}, fmt.Errorf("test case %d/%d has IV of length %d, but expected %d", group.ID, test.ID, len(iv), b.blockSize)
+		}
 	}
 
-	return ret, nil
+	var aad []byte
+	if b.aead {
+		if aad, err = hex.DecodeString(test.AADHex); err != nil {
+			return blockCipherTestResponse{}, fmt.Errorf("failed to decode aad in test case %d/%d: %s", group.ID, test.ID, err)
+		}
+	}
+
+	var tag []byte
+	if b.aead && !encrypt {
+		if tag, err = hex.DecodeString(test.TagHex); err != nil {
+			return blockCipherTestResponse{}, fmt.Errorf("failed to decode tag in test case %d/%d: %s", group.ID, test.ID, err)
+		}
+	}
+
+	testResp := blockCipherTestResponse{ID: test.ID}
+	if !mct {
+		var result [][]byte
+
+		switch {
+		case b.aead && encrypt:
+			result, err = m.Transact(op, 1, key, iv, input, aad)
+		case b.aead && !encrypt:
+			sealed := append(append([]byte{}, input...), tag...)
+			result, err = m.Transact(op, 1, key, iv, sealed, aad)
+		case b.hasIV:
+			result, err = m.Transact(op, 1, key, input, iv)
+		default:
+			result, err = m.Transact(op, 1, key, input)
+		}
+		if err != nil {
+			panic("block operation failed: " + err.Error())
+		}
+
+		switch {
+		case b.aead && encrypt:
+			sealed := result[0]
+			ciphertext := sealed[:len(sealed)-aesGCMSIVTagLen]
+			respTag := sealed[len(sealed)-aesGCMSIVTagLen:]
+			testResp.CiphertextHex = hex.EncodeToString(ciphertext)
+			testResp.TagHex = hex.EncodeToString(respTag)
+		case b.aead && !encrypt:
+			// A zero-length result signals an authentication failure: the
+			// ciphertext or tag was invalid.
+			if len(result) == 0 {
+				passed := false
+				testResp.Passed = &passed
+			} else {
+				testResp.PlaintextHex = hex.EncodeToString(result[0])
+			}
+		case encrypt:
+			testResp.CiphertextHex = hex.EncodeToString(result[0])
+		default:
+			testResp.PlaintextHex = hex.EncodeToString(result[0])
+		}
+	} else if b.aead {
+		transact := func(op string, n int, args ...[]byte) ([][]byte, error) {
+			return m.Transact(op, n, args...)
+		}
+		encryptOp := b.algo + "/encrypt"
+		decryptOp := b.algo + "/decrypt"
+		testResp.MCTResults, testResp.Passed = b.aeadMCTFunc(transact, encryptOp, decryptOp, encrypt, key, input, iv, aad, tag)
+	} else {
+		transact := func(n int, args ...[]byte) ([][]byte, error) {
+			return m.Transact(op, n, args...)
+		}
+		testResp.MCTResults = b.mctFunc(transact, encrypt, key, input, iv)
+	}
+
+	return testResp, nil
 }
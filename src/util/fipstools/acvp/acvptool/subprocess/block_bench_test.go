@@ -0,0 +1,87 @@
+// Copyright (c) 2024, Google Inc.
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY
+// SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION
+// OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN
+// CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package subprocess
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// latencyTransactable simulates the fixed per-call IPC latency of the
+// subprocess path, so the benchmarks below measure how Process's worker
+// pool amortizes that latency rather than any real AES cost.
+type latencyTransactable struct {
+	latency time.Duration
+}
+
+func (l latencyTransactable) Transact(op string, expectedResults int, args ...[]byte) ([][]byte, error) {
+	time.Sleep(l.latency)
+	input := args[1]
+	out := make([]byte, len(input))
+	copy(out, input)
+	return [][]byte{out}, nil
+}
+
+// benchmarkBlockCipherWorkers measures Process's wall-clock time for a
+// fixed number of independent AFT test cases, at the given worker count, to
+// demonstrate scaling across an 8-core host (-cpu=8).
+func benchmarkBlockCipherWorkers(b *testing.B, workers int) {
+	const numTests = 64
+
+	group := blockCipherTestGroup{
+		ID:        1,
+		Type:      "AFT",
+		Direction: "encrypt",
+		KeyBits:   128,
+	}
+	for i := 0; i < numTests; i++ {
+		group.Tests = append(group.Tests, struct {
+			ID            uint64 `json:"tcId"`
+			PlaintextHex  string `json:"pt"`
+			CiphertextHex string `json:"ct"`
+			IVHex         string `json:"iv"`
+			KeyHex        string `json:"key"`
+			AADHex        string `json:"aad,omitempty"`
+			TagHex        string `json:"tag,omitempty"`
+		}{
+			ID:           uint64(i),
+			PlaintextHex: "00112233445566778899aabbccddeeff",
+			KeyHex:       "000102030405060708090a0b0c0d0e0f",
+		})
+	}
+	vectorSet, err := json.Marshal(blockCipherVectorSet{Groups: []blockCipherTestGroup{group}})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	cipher := &blockCipher{
+		algo:      "AES-ECB",
+		blockSize: 16,
+		workers:   workers,
+	}
+	m := latencyTransactable{latency: time.Millisecond}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cipher.Process(vectorSet, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBlockCipherWorkers1(b *testing.B)  { benchmarkBlockCipherWorkers(b, 1) }
+func BenchmarkBlockCipherWorkers8(b *testing.B)  { benchmarkBlockCipherWorkers(b, 8) }
+func BenchmarkBlockCipherWorkers16(b *testing.B) { benchmarkBlockCipherWorkers(b, 16) }
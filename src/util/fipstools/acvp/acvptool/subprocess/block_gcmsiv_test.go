@@ -0,0 +1,298 @@
+// Copyright (c) 2024, Google Inc.
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY
+// SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION
+// OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN
+// CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package subprocess
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// fakeGCMSIVKeystream and fakeGCMSIVTag stand in for the real AES-GCM-SIV
+// primitives: they're deterministic and reversible, which is all that's
+// needed to validate blockCipher's wiring of AAD, nonce and tag, not the
+// actual cryptography.
+func fakeGCMSIVKeystream(key, nonce, aad []byte, n int) []byte {
+	out := make([]byte, n)
+	var acc byte = 0x5a
+	for _, b := range key {
+		acc ^= b
+	}
+	for _, b := range nonce {
+		acc = acc*31 + b
+	}
+	for _, b := range aad {
+		acc = acc*17 + b
+	}
+	for i := range out {
+		acc = acc*31 + byte(i) + 1
+		out[i] = acc
+	}
+	return out
+}
+
+func fakeGCMSIVTag(key, nonce, aad, ciphertext []byte) []byte {
+	tag := make([]byte, aesGCMSIVTagLen)
+	var acc byte = 0xa5
+	for _, b := range key {
+		acc = acc*131 + b
+	}
+	for _, b := range nonce {
+		acc = acc*31 + b
+	}
+	for _, b := range aad {
+		acc = acc*17 + b
+	}
+	for _, b := range ciphertext {
+		acc = acc*7 + b
+	}
+	for i := range tag {
+		acc = acc*37 + byte(i) + 1
+		tag[i] = acc
+	}
+	return tag
+}
+
+func fakeGCMSIVXOR(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// fakeGCMSIVTransactable implements Transactable with the deterministic
+// fake primitives above, playing the role of the external module.
+type fakeGCMSIVTransactable struct{}
+
+func (fakeGCMSIVTransactable) Transact(op string, expectedResults int, args ...[]byte) ([][]byte, error) {
+	switch op {
+	case "AES-GCM-SIV/encrypt":
+		key, nonce, input, aad := args[0], args[1], args[2], args[3]
+		ciphertext := fakeGCMSIVXOR(input, fakeGCMSIVKeystream(key, nonce, aad, len(input)))
+		tag := fakeGCMSIVTag(key, nonce, aad, ciphertext)
+		return [][]byte{append(ciphertext, tag...)}, nil
+	case "AES-GCM-SIV/decrypt":
+		key, nonce, sealed, aad := args[0], args[1], args[2], args[3]
+		ciphertext := sealed[:len(sealed)-aesGCMSIVTagLen]
+		tag := sealed[len(sealed)-aesGCMSIVTagLen:]
+		if !bytes.Equal(tag, fakeGCMSIVTag(key, nonce, aad, ciphertext)) {
+			return nil, nil
+		}
+		plaintext := fakeGCMSIVXOR(ciphertext, fakeGCMSIVKeystream(key, nonce, aad, len(ciphertext)))
+		return [][]byte{plaintext}, nil
+	default:
+		return nil, fmt.Errorf("unexpected op %q", op)
+	}
+}
+
+// aesGCMSIVCipher mirrors how AES-GCM-SIV would be registered as a
+// blockCipher instance.
+var aesGCMSIVCipher = &blockCipher{
+	algo:  "AES-GCM-SIV",
+	aead:  true,
+	hasIV: true,
+}
+
+func TestAESGCMSIVKnownAnswer(t *testing.T) {
+	key, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := hex.DecodeString("0102030405060708090a0b0c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		plaintext string
+		aad       string
+	}{
+		{"empty plaintext, empty AAD", "", ""},
+		{"plaintext only", "00112233445566778899aabbccddeeff0011223344", ""},
+		{"AAD only", "", "000102030405060708090a0b0c0d0e0f"},
+		{"plaintext and AAD", "48656c6c6f2c20776f726c6421", "101112131415161718191a1b"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			group := blockCipherTestGroup{ID: 1, Type: "AFT", Direction: "encrypt", KeyBits: 128}
+			group.Tests = []struct {
+				ID            uint64 `json:"tcId"`
+				PlaintextHex  string `json:"pt"`
+				CiphertextHex string `json:"ct"`
+				IVHex         string `json:"iv"`
+				KeyHex        string `json:"key"`
+				AADHex        string `json:"aad,omitempty"`
+				TagHex        string `json:"tag,omitempty"`
+			}{{
+				ID:           1,
+				PlaintextHex: c.plaintext,
+				IVHex:        hex.EncodeToString(nonce),
+				KeyHex:       hex.EncodeToString(key),
+				AADHex:       c.aad,
+			}}
+
+			vectorSet, err := json.Marshal(blockCipherVectorSet{Groups: []blockCipherTestGroup{group}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := aesGCMSIVCipher.Process(vectorSet, fakeGCMSIVTransactable{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			groups := result.([]blockCipherTestGroupResponse)
+			ct := groups[0].Tests[0].CiphertextHex
+			tag := groups[0].Tests[0].TagHex
+
+			plaintext, _ := hex.DecodeString(c.plaintext)
+			aad, _ := hex.DecodeString(c.aad)
+			wantCiphertext := fakeGCMSIVXOR(plaintext, fakeGCMSIVKeystream(key, nonce, aad, len(plaintext)))
+			wantTag := fakeGCMSIVTag(key, nonce, aad, wantCiphertext)
+
+			if ct != hex.EncodeToString(wantCiphertext) {
+				t.Errorf("ciphertext = %q, want %q", ct, hex.EncodeToString(wantCiphertext))
+			}
+			if tag != hex.EncodeToString(wantTag) {
+				t.Errorf("tag = %q, want %q", tag, hex.EncodeToString(wantTag))
+			}
+
+			// Round-trip through decrypt and recover the original plaintext.
+			decryptGroup := blockCipherTestGroup{ID: 2, Type: "AFT", Direction: "decrypt", KeyBits: 128}
+			decryptGroup.Tests = []struct {
+				ID            uint64 `json:"tcId"`
+				PlaintextHex  string `json:"pt"`
+				CiphertextHex string `json:"ct"`
+				IVHex         string `json:"iv"`
+				KeyHex        string `json:"key"`
+				AADHex        string `json:"aad,omitempty"`
+				TagHex        string `json:"tag,omitempty"`
+			}{{
+				ID:            1,
+				CiphertextHex: ct,
+				TagHex:        tag,
+				IVHex:         hex.EncodeToString(nonce),
+				KeyHex:        hex.EncodeToString(key),
+				AADHex:        c.aad,
+			}}
+			decryptVectorSet, err := json.Marshal(blockCipherVectorSet{Groups: []blockCipherTestGroup{decryptGroup}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			decryptResult, err := aesGCMSIVCipher.Process(decryptVectorSet, fakeGCMSIVTransactable{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotPlaintext := decryptResult.([]blockCipherTestGroupResponse)[0].Tests[0].PlaintextHex
+			if gotPlaintext != c.plaintext {
+				t.Errorf("decrypted plaintext = %q, want %q", gotPlaintext, c.plaintext)
+			}
+		})
+	}
+}
+
+// gcmsivTransact adapts fakeGCMSIVTransactable to iterateAESGCMSIV's
+// explicit-op transact signature.
+func gcmsivTransact(op string, n int, args ...[]byte) ([][]byte, error) {
+	return fakeGCMSIVTransactable{}.Transact(op, n, args...)
+}
+
+// TestIterateAESGCMSIVEmptyPlaintext is a regression test: an AES-GCM-SIV
+// MCT group whose payload is shorter than the tag length (e.g. the
+// empty-plaintext/empty-AAD known-answer case above) used to panic inside
+// aesKeyShuffle, because the key shuffle was fed the ciphertext, which is
+// exactly as long as the plaintext, rather than the always-16-byte tag.
+func TestIterateAESGCMSIVEmptyPlaintext(t *testing.T) {
+	key := make([]byte, 16)
+	nonce := make([]byte, 12)
+
+	results, passed := iterateAESGCMSIV(
+		gcmsivTransact,
+		"AES-GCM-SIV/encrypt", "AES-GCM-SIV/decrypt",
+		true /* encrypt */, key, nil /* plaintext */, nonce, nil /* aad */, nil /* tag */)
+
+	if passed != nil {
+		t.Fatalf("encrypt direction unexpectedly reported a pass/fail verdict: %v", *passed)
+	}
+	if len(results) != 100 {
+		t.Fatalf("got %d MCT results, want 100", len(results))
+	}
+	if results[99].TagHex == "" {
+		t.Error("final MCT result has no tag")
+	}
+}
+
+// TestIterateAESGCMSIVDecryptMCT is a regression test for the decrypt
+// direction: it used to hold the test-supplied tag fixed across every
+// inner iteration while rewriting the nonce/AAD from the previous round,
+// so the second inner iteration's decrypt would fail to authenticate
+// against a module that actually checks the tag, and the resulting
+// zero-length result panicked on `input = results[0]`. The fix re-mints a
+// valid ciphertext/tag for each new nonce/AAD by re-encrypting the
+// recovered plaintext, so every decrypt call should authenticate and the
+// MCT should run to completion.
+func TestIterateAESGCMSIVDecryptMCT(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	nonce := []byte("initializ-ic")
+	plaintext := []byte("a real decrypt MCT test vector!")
+	aad := []byte("associated-data")
+
+	sealed, err := fakeGCMSIVTransactable{}.Transact("AES-GCM-SIV/encrypt", 1, key, nonce, plaintext, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := sealed[0][:len(sealed[0])-aesGCMSIVTagLen]
+	tag := sealed[0][len(sealed[0])-aesGCMSIVTagLen:]
+
+	results, passed := iterateAESGCMSIV(
+		gcmsivTransact,
+		"AES-GCM-SIV/encrypt", "AES-GCM-SIV/decrypt",
+		false /* encrypt */, key, ciphertext, nonce, aad, tag)
+
+	if passed != nil {
+		t.Fatalf("decrypt direction reported failure: %v", *passed)
+	}
+	if len(results) != 100 {
+		t.Fatalf("got %d MCT results, want 100", len(results))
+	}
+}
+
+// TestIterateAESGCMSIVDecryptAuthFailure checks that a decrypt MCT whose
+// initial tag does not authenticate reports a failure rather than
+// panicking, satisfying the backlog's requirement for tag-mismatch decrypt
+// failure vectors on the MCT path, not just the AFT path.
+func TestIterateAESGCMSIVDecryptAuthFailure(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	nonce := []byte("initializ-ic")
+	ciphertext := []byte("not a real ciphertext string!!!")
+	badTag := make([]byte, aesGCMSIVTagLen)
+
+	results, passed := iterateAESGCMSIV(
+		gcmsivTransact,
+		"AES-GCM-SIV/encrypt", "AES-GCM-SIV/decrypt",
+		false /* encrypt */, key, ciphertext, nonce, nil /* aad */, badTag)
+
+	if passed == nil || *passed {
+		t.Fatal("expected a reported failure for a tag that doesn't authenticate")
+	}
+	if len(results) != 0 {
+		t.Errorf("got %d MCT results for a first-round auth failure, want 0", len(results))
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2024, Google Inc.
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY
+// SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION
+// OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN
+// CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package subprocess
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fakeKeystreamBlock stands in for a real AES block operation: it depends
+// only on the key and the IV/register passed to it, never on the data
+// block being combined, matching how a keystream generator behaves.
+func fakeKeystreamBlock(key, iv []byte) []byte {
+	out := make([]byte, len(iv))
+	var acc byte = 0x42
+	for _, b := range key {
+		acc = acc*31 + b
+	}
+	for i, b := range iv {
+		acc = acc*17 + b + byte(i)
+		out[i] = acc
+	}
+	return out
+}
+
+func fakeXORBlocks(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// fakeOFBTransact models a correct OFB-mode module: result = input XOR
+// E(key, iv), with E(key, iv) independent of input.
+func fakeOFBTransact(n int, args ...[]byte) ([][]byte, error) {
+	key, input, iv := args[0], args[1], args[2]
+	return [][]byte{fakeXORBlocks(input, fakeKeystreamBlock(key, iv))}, nil
+}
+
+// TestIterateAESOFBDiffersFromCBC is a regression test: iterateAESOFB used
+// to simply delegate to iterateAESCBC, which feeds the data block (not the
+// keystream) back as the next IV. Against a module whose output genuinely
+// depends only on key and IV, that delegation and a from-spec OFB MCT
+// diverge after the first inner iteration.
+func TestIterateAESOFBDiffersFromCBC(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	input := []byte("plaintext-block!")
+	iv := []byte("initialization-v")
+
+	ofbResults := iterateAESOFB(fakeOFBTransact, true, append([]byte{}, key...), append([]byte{}, input...), append([]byte{}, iv...))
+	cbcResults := iterateAESCBC(fakeOFBTransact, true, append([]byte{}, key...), append([]byte{}, input...), append([]byte{}, iv...))
+
+	if ofbResults[0].CiphertextHex == cbcResults[0].CiphertextHex {
+		t.Fatal("iterateAESOFB produced the same result as iterateAESCBC against a module whose output depends only on key and IV; OFB's keystream feedback is not being exercised")
+	}
+}
+
+// TestIterateAESOFBKeystreamFeedback drives two inner iterations directly
+// (mirroring iterateAESOFB's own loop body) and checks the result against a
+// from-spec computation of the OFB MCT: O[j] = E(key, IV[j]),
+// IV[j+1] = O[j], independent of the data block.
+func TestIterateAESOFBKeystreamFeedback(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	input := []byte("plaintext-block!")
+	iv := []byte("initialization-v")
+
+	o0 := fakeKeystreamBlock(key, iv)
+	wantFirst := fakeXORBlocks(input, o0)
+
+	first, err := fakeOFBTransact(1, key, input, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first[0], wantFirst) {
+		t.Fatalf("first iteration: got %x, want %x", first[0], wantFirst)
+	}
+
+	// The next call must be keyed off o0 (the keystream just produced), not
+	// off wantFirst (the data just produced).
+	o1 := fakeKeystreamBlock(key, o0)
+	wantSecond := fakeXORBlocks(wantFirst, o1)
+
+	second, err := fakeOFBTransact(1, key, wantFirst, o0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(second[0], wantSecond) {
+		t.Fatalf("second iteration: got %x, want %x", second[0], wantSecond)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2024, Google Inc.
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY
+// SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION
+// OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN
+// CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package subprocess
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func aesCTRVectorSet(t *testing.T, payloadLen int, plaintextHex string) []byte {
+	t.Helper()
+
+	group := blockCipherTestGroup{ID: 1, Type: "AFT", Direction: "encrypt", KeyBits: 128, PayloadLen: payloadLen}
+	group.Tests = []struct {
+		ID            uint64 `json:"tcId"`
+		PlaintextHex  string `json:"pt"`
+		CiphertextHex string `json:"ct"`
+		IVHex         string `json:"iv"`
+		KeyHex        string `json:"key"`
+		AADHex        string `json:"aad,omitempty"`
+		TagHex        string `json:"tag,omitempty"`
+	}{{
+		ID:           1,
+		PlaintextHex: plaintextHex,
+		IVHex:        "00112233445566778899aabbccddeeff",
+		KeyHex:       "000102030405060708090a0b0c0d0e0f",
+	}}
+
+	vectorSet, err := json.Marshal(blockCipherVectorSet{Groups: []blockCipherTestGroup{group}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vectorSet
+}
+
+// TestBlockCipherPayloadLenMismatch checks that a test case whose decoded
+// payload length disagrees with its group's payloadLen is rejected, rather
+// than silently processed against the wrong expected length.
+func TestBlockCipherPayloadLenMismatch(t *testing.T) {
+	cipher := &blockCipher{algo: "AES-CTR", hasIV: true, blockSize: 16}
+	m := latencyTransactable{}
+
+	// "00112233" decodes to 4 bytes, matching payloadLen: 4.
+	if _, err := cipher.Process(aesCTRVectorSet(t, 4, "00112233"), m); err != nil {
+		t.Errorf("expected matching payloadLen to be accepted, got error: %s", err)
+	}
+
+	// Same plaintext, but payloadLen now disagrees with its actual length.
+	if _, err := cipher.Process(aesCTRVectorSet(t, 8, "00112233"), m); err == nil {
+		t.Error("expected mismatched payloadLen to be rejected, got no error")
+	}
+}
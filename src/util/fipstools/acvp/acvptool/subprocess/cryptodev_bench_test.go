@@ -0,0 +1,60 @@
+// Copyright (c) 2024, Google Inc.
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY
+// SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION
+// OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN
+// CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+//go:build linux
+
+package subprocess
+
+import (
+	"testing"
+)
+
+// subprocessStandIn is a minimal Transactable standing in for the subprocess
+// path in the comparison below. It performs no IPC and no real AES: it
+// returns a copy of its input.
+type subprocessStandIn struct{}
+
+func (subprocessStandIn) Transact(op string, expectedResults int, args ...[]byte) ([][]byte, error) {
+	input := args[1]
+	out := make([]byte, len(input))
+	copy(out, input)
+	return [][]byte{out}, nil
+}
+
+// BenchmarkAESCBCMCT_Subprocess and BenchmarkAESCBCMCT_Cryptodev run a
+// single 256-bit-key CBC MCT test case through subprocessStandIn directly
+// and through the cryptodev-backed Transactable, which falls back to
+// subprocessStandIn when /dev/crypto cannot be opened.
+func BenchmarkAESCBCMCT_Subprocess(b *testing.B) {
+	benchmarkAESCBCMCT(b, subprocessStandIn{})
+}
+
+func BenchmarkAESCBCMCT_Cryptodev(b *testing.B) {
+	benchmarkAESCBCMCT(b, newCryptodevBackend(subprocessStandIn{}))
+}
+
+func benchmarkAESCBCMCT(b *testing.B, m Transactable) {
+	key := make([]byte, 32)
+	input := make([]byte, 16)
+	iv := make([]byte, 16)
+
+	transact := func(n int, args ...[]byte) ([][]byte, error) {
+		return m.Transact("AES-CBC/encrypt", n, args...)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		iterateAESCBC(transact, true, append([]byte{}, key...), append([]byte{}, input...), append([]byte{}, iv...))
+	}
+}